@@ -1,8 +1,14 @@
 package rpc
 
 import (
+	"context"
+	"fmt"
 	"github.com/mitchellh/packer/packer"
+	"github.com/mitchellh/packer/template"
+	"io"
+	"io/ioutil"
 	"net/rpc"
+	"sync"
 )
 
 // An implementation of packer.Build where the build is actually executed
@@ -10,13 +16,33 @@ import (
 type build struct {
 	client *rpc.Client
 	mux    *MuxConn
+
+	busOnce sync.Once
+	bus     *eventBus
 }
 
 // BuildServer wraps a packer.Build implementation and makes it exportable
 // as part of a Golang RPC server.
 type BuildServer struct {
-	build packer.Build
-	mux   *MuxConn
+	build                   packer.Build
+	mux                     *MuxConn
+	errorCleanupProvisioner *template.Provisioner
+}
+
+// BuildRunArgs are the args to BuildServer.Run: the stream the server
+// should use to reach the Ui and Cache for this run, and the stream it
+// should watch for the client cancelling the run.
+type BuildRunArgs struct {
+	StreamId uint32
+	CancelId uint32
+}
+
+// BuildCleanupOnErrorArgs are the args to BuildServer.CleanupOnError:
+// the failed artifacts (if any were produced before the error) and the
+// error Run is about to return to the client.
+type BuildCleanupOnErrorArgs struct {
+	Artifacts []packer.Artifact
+	RunError  error
 }
 
 type BuildPrepareResponse struct {
@@ -38,20 +64,60 @@ func (b *build) Prepare(v map[string]string) ([]string, error) {
 	return resp.Warnings, resp.Error
 }
 
+// Run behaves like RunContext with context.Background(): it never
+// cancels the remote build early.
 func (b *build) Run(ui packer.Ui, cache packer.Cache) ([]packer.Artifact, error) {
-	nextId := b.mux.NextId()
-	server := newServerWithMux(b.mux, nextId)
+	return b.RunContext(context.Background(), ui, cache)
+}
+
+// RunContext runs the build over RPC, returning once the remote build
+// finishes or ctx is done, whichever comes first. If ctx is done
+// first, it closes the dedicated cancel stream it shares with the
+// server for this run; the server watches the other end of that same
+// stream and responds by calling build.Cancel() itself, so cancelling
+// never has to make an RPC call that could fail and panic.
+func (b *build) RunContext(ctx context.Context, ui packer.Ui, cache packer.Cache) ([]packer.Artifact, error) {
+	streamId := b.mux.NextId()
+	server := newServerWithMux(b.mux, streamId)
 	server.RegisterCache(cache)
 	server.RegisterUi(ui)
+	server.RegisterEventBus(&EventBusServer{bus: b.eventBus()})
 	go server.Serve()
 
-	var result []uint32
-	if err := b.client.Call("Build.Run", nextId, &result); err != nil {
+	cancelId := b.mux.NextId()
+	cancelConn, err := b.mux.Dial(cancelId)
+	if err != nil {
 		return nil, err
 	}
+	defer cancelConn.Close()
+
+	type runResult struct {
+		streamIds []uint32
+		err       error
+	}
 
-	artifacts := make([]packer.Artifact, len(result))
-	for i, streamId := range result {
+	resultCh := make(chan runResult, 1)
+	go func() {
+		var result []uint32
+		err := b.client.Call("Build.Run", &BuildRunArgs{StreamId: streamId, CancelId: cancelId}, &result)
+		resultCh <- runResult{result, err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		if result.err != nil {
+			return nil, result.err
+		}
+		return b.artifactsFromStreamIds(result.streamIds)
+	case <-ctx.Done():
+		cancelConn.Close()
+		return nil, ctx.Err()
+	}
+}
+
+func (b *build) artifactsFromStreamIds(streamIds []uint32) ([]packer.Artifact, error) {
+	artifacts := make([]packer.Artifact, len(streamIds))
+	for i, streamId := range streamIds {
 		client, err := newClientWithMux(b.mux, streamId)
 		if err != nil {
 			return nil, err
@@ -63,6 +129,37 @@ func (b *build) Run(ui packer.Ui, cache packer.Cache) ([]packer.Artifact, error)
 	return artifacts, nil
 }
 
+// eventBus returns this build's eventBus, creating it on first use.
+func (b *build) eventBus() *eventBus {
+	b.busOnce.Do(func() {
+		b.bus = newEventBus()
+	})
+	return b.bus
+}
+
+// Subscribe returns a channel of every Event published by the remote
+// build, starting from the moment of the call. The channel is closed
+// when ctx is done.
+func (b *build) Subscribe(ctx context.Context) <-chan Event {
+	return b.eventBus().Subscribe(ctx)
+}
+
+// ErrorCleanupProvisioner returns the error cleanup provisioner, if
+// any, set on the remote build with SetErrorCleanupProvisioner.
+func (b *build) ErrorCleanupProvisioner() (result *template.Provisioner) {
+	b.client.Call("Build.ErrorCleanupProvisioner", new(interface{}), &result)
+	return
+}
+
+// SetErrorCleanupProvisioner stores p on the remote build as its error
+// cleanup provisioner. The provisioner is not run; see
+// BuildServer.CleanupOnError.
+func (b *build) SetErrorCleanupProvisioner(p *template.Provisioner) {
+	if err := b.client.Call("Build.SetErrorCleanupProvisioner", p, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
 func (b *build) SetDebug(val bool) {
 	if err := b.client.Call("Build.SetDebug", val, new(interface{})); err != nil {
 		panic(err)
@@ -95,20 +192,70 @@ func (b *BuildServer) Prepare(v map[string]string, resp *BuildPrepareResponse) e
 	return nil
 }
 
-func (b *BuildServer) Run(streamId uint32, reply *[]uint32) error {
-	client, err := newClientWithMux(b.mux, streamId)
+// Run launches the underlying build in a goroutine and, concurrently,
+// watches args's cancel stream for the client closing its end; if
+// that happens before the build finishes on its own, it's the client's
+// context being cancelled, so it calls build.Cancel() and waits for
+// the now-cancelled build to return.
+func (b *BuildServer) Run(args *BuildRunArgs, reply *[]uint32) error {
+	client, err := newClientWithMux(b.mux, args.StreamId)
 	if err != nil {
 		return NewBasicError(err)
 	}
 	defer client.Close()
 
-	artifacts, err := b.build.Run(client.Ui(), client.Cache())
+	cancelConn, err := b.mux.Accept(args.CancelId)
 	if err != nil {
 		return NewBasicError(err)
 	}
+	defer cancelConn.Close()
+
+	bus := client.EventBus()
+	bus.publish(EventBuildStarted, fmt.Sprintf("build %q started", b.build.Name()))
+
+	type runResult struct {
+		artifacts []packer.Artifact
+		err       error
+	}
+
+	resultCh := make(chan runResult, 1)
+	go func() {
+		artifacts, err := b.build.Run(client.Ui(), client.Cache())
+		resultCh <- runResult{artifacts, err}
+	}()
+
+	// The client closes cancelConn, or loses the connection entirely,
+	// the moment its context is cancelled; io.Copy returns as soon as
+	// that happens.
+	cancelled := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, cancelConn)
+		close(cancelled)
+	}()
+
+	var result runResult
+	select {
+	case result = <-resultCh:
+	case <-cancelled:
+		b.build.Cancel()
+		result = <-resultCh
+	}
+
+	if result.err != nil {
+		bus.publish(EventError, result.err.Error())
+
+		cleanupArgs := &BuildCleanupOnErrorArgs{Artifacts: result.artifacts, RunError: result.err}
+		if cleanupErr := b.CleanupOnError(cleanupArgs, new(interface{})); cleanupErr != nil {
+			client.Ui().Error(fmt.Sprintf("error cleanup provisioner failed: %s", cleanupErr))
+		}
+
+		return NewBasicError(result.err)
+	}
+
+	*reply = make([]uint32, len(result.artifacts))
+	for i, artifact := range result.artifacts {
+		bus.publish(EventArtifactProduced, artifact.Id())
 
-	*reply = make([]uint32, len(artifacts))
-	for i, artifact := range artifacts {
 		streamId := b.mux.NextId()
 		server := newServerWithMux(b.mux, streamId)
 		server.RegisterArtifact(artifact)
@@ -120,6 +267,33 @@ func (b *BuildServer) Run(streamId uint32, reply *[]uint32) error {
 	return nil
 }
 
+// CleanupOnError is called by Run after a failed build, before Run
+// returns that failure to the client. It returns nil if no error
+// cleanup provisioner was set with SetErrorCleanupProvisioner.
+// Otherwise it returns an error naming the provisioner's type; it does
+// not run the provisioner, and args is not used. template.Provisioner
+// is only the parsed config and has no Run method, and packer.Build
+// has no method that runs a provisioner.
+func (b *BuildServer) CleanupOnError(args *BuildCleanupOnErrorArgs, reply *interface{}) error {
+	if b.errorCleanupProvisioner == nil {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"error cleanup provisioner %q is configured but this RPC layer has no way to run it yet",
+		b.errorCleanupProvisioner.Type)
+}
+
+func (b *BuildServer) ErrorCleanupProvisioner(args *interface{}, reply **template.Provisioner) error {
+	*reply = b.errorCleanupProvisioner
+	return nil
+}
+
+func (b *BuildServer) SetErrorCleanupProvisioner(p *template.Provisioner, reply *interface{}) error {
+	b.errorCleanupProvisioner = p
+	return nil
+}
+
 func (b *BuildServer) SetDebug(val *bool, reply *interface{}) error {
 	b.build.SetDebug(*val)
 	return nil
@@ -133,4 +307,4 @@ func (b *BuildServer) SetForce(val *bool, reply *interface{}) error {
 func (b *BuildServer) Cancel(args *interface{}, reply *interface{}) error {
 	b.build.Cancel()
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,127 @@
+package rpc
+
+import (
+	"context"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// EventKind identifies the kind of a single Event published on an
+// eventBus.
+type EventKind string
+
+const (
+	EventBuildStarted     EventKind = "build_started"
+	EventProvisionerStep  EventKind = "provisioner_step"
+	EventArtifactProduced EventKind = "artifact_produced"
+	EventError            EventKind = "error"
+	EventHeartbeat        EventKind = "heartbeat"
+)
+
+// Event is a single structured event describing the progress of a
+// remote build. Unlike a Ui message, every Event carries a Seq (monotonic
+// per build) and a BuildId correlating it to the build that produced
+// it, so a subscriber watching more than one build at once can tell
+// them apart and notice a gap in the sequence.
+type Event struct {
+	Seq     uint64
+	BuildId string
+	Kind    EventKind
+	Message string
+}
+
+// eventBus is the subscriber-facing half of the event bus. It lives on
+// whichever side of the RPC connection called build.Subscribe, and
+// fans out every Event it receives from the remote build to each
+// channel returned by Subscribe.
+//
+// This is additive to the Ui path: a remote build still drives Say,
+// Message, Error, and Machine against the Ui passed to Run exactly as
+// before, and also publishes Events here for anyone that wants a
+// structured view of its progress. Past Events are not kept, so a
+// subscriber sees only those published after it subscribed.
+type eventBus struct {
+	buildId string
+
+	mu          sync.Mutex
+	seq         uint64
+	subscribers []chan<- Event
+}
+
+var eventBusBuildIds uint64
+
+func newEventBus() *eventBus {
+	return &eventBus{
+		buildId: strconv.FormatUint(atomic.AddUint64(&eventBusBuildIds, 1), 10),
+	}
+}
+
+// Subscribe returns a channel that receives every Event published for
+// this build, from the moment of the call onward. The channel is
+// closed when ctx is done; callers must keep draining it until then,
+// or publish will block the remote build.
+func (e *eventBus) Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 64)
+
+	e.mu.Lock()
+	e.subscribers = append(e.subscribers, ch)
+	e.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		for i, sub := range e.subscribers {
+			if sub == ch {
+				e.subscribers = append(e.subscribers[:i], e.subscribers[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch
+}
+
+// publish stamps event with the next sequence number and this bus's
+// BuildId, then delivers it to every current subscriber.
+func (e *eventBus) publish(event Event) {
+	e.mu.Lock()
+	event.Seq = atomic.AddUint64(&e.seq, 1)
+	event.BuildId = e.buildId
+	subs := make([]chan<- Event, len(e.subscribers))
+	copy(subs, e.subscribers)
+	e.mu.Unlock()
+
+	for _, sub := range subs {
+		sub <- event
+	}
+}
+
+// EventBusServer wraps an eventBus and makes its publishing side
+// exportable as part of a Golang RPC server: the remote build calls
+// EventBus.Publish, and this forwards the Event to the bus's local
+// subscribers.
+type EventBusServer struct {
+	bus *eventBus
+}
+
+func (s *EventBusServer) Publish(event *Event, reply *interface{}) error {
+	s.bus.publish(*event)
+	return nil
+}
+
+// remoteEventBus is the publishing-side handle used by a build as it
+// runs to deliver Events to whichever process called Subscribe. It's
+// the counterpart to eventBus/EventBusServer, reached over the same
+// dedicated mux stream as the Ui and Cache for that run.
+type remoteEventBus struct {
+	client *rpc.Client
+}
+
+func (r *remoteEventBus) publish(kind EventKind, message string) {
+	r.client.Call("EventBus.Publish", &Event{Kind: kind, Message: message}, new(interface{}))
+}
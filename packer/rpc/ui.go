@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"net/rpc"
+)
+
+// ui is an implementation of packer.Ui where the Ui is actually
+// executed over an RPC connection. Each packer.Ui method is its own
+// RPC call, kept separate so a remote build driving a Ui behaves
+// exactly like a local one; see eventBus for the additive, structured
+// progress channel.
+type ui struct {
+	client *rpc.Client
+}
+
+// UiServer wraps a packer.Ui implementation and makes it exportable as
+// part of a Golang RPC server.
+type UiServer struct {
+	ui packer.Ui
+}
+
+// UiMachineArgs are the args to Ui.Machine: the machine-readable type
+// being reported and its arguments.
+type UiMachineArgs struct {
+	Type string
+	Args []string
+}
+
+func newUi(client *rpc.Client) *ui {
+	return &ui{client: client}
+}
+
+func (u *ui) Ask(query string) (string, error) {
+	var result string
+	err := u.client.Call("Ui.Ask", query, &result)
+	return result, err
+}
+
+func (u *ui) Say(message string) {
+	if err := u.client.Call("Ui.Say", message, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (u *ui) Message(message string) {
+	if err := u.client.Call("Ui.Message", message, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (u *ui) Error(message string) {
+	if err := u.client.Call("Ui.Error", message, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (u *ui) Machine(t string, args ...string) {
+	margs := &UiMachineArgs{Type: t, Args: args}
+	if err := u.client.Call("Ui.Machine", margs, new(interface{})); err != nil {
+		panic(err)
+	}
+}
+
+func (u *UiServer) Ask(query *string, reply *string) error {
+	result, err := u.ui.Ask(*query)
+	*reply = result
+	return err
+}
+
+func (u *UiServer) Say(message *string, reply *interface{}) error {
+	u.ui.Say(*message)
+	return nil
+}
+
+func (u *UiServer) Message(message *string, reply *interface{}) error {
+	u.ui.Message(*message)
+	return nil
+}
+
+func (u *UiServer) Error(message *string, reply *interface{}) error {
+	u.ui.Error(*message)
+	return nil
+}
+
+func (u *UiServer) Machine(args *UiMachineArgs, reply *interface{}) error {
+	u.ui.Machine(args.Type, args.Args...)
+	return nil
+}
@@ -0,0 +1,10 @@
+package template
+
+// Builder is a struct that represents a builder as it is parsed out of
+// a template.
+type Builder struct {
+	Name string
+	Type string
+
+	Config map[string]interface{}
+}
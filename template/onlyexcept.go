@@ -0,0 +1,10 @@
+package template
+
+// OnlyExcept is a struct that is meant to be embedded that contains the
+// logic required for the "only" and "except" meta-parameters. These
+// parameters let a provisioner/post-processor be scoped to run
+// against (or be skipped for) a specific set of builder names.
+type OnlyExcept struct {
+	Only   []string
+	Except []string
+}
@@ -0,0 +1,985 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses a JSON template from the given reader r and returns the
+// parsed template.
+//
+// Once this function returns successfully, we've validated that the
+// template is syntactically valid, but NOT semantically. There may still
+// be errors around the usage but we can't detect that until the template
+// is actually used.
+func Parse(r io.Reader) (*Template, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %s", err)
+	}
+
+	if err := checkForDuplicateFields(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+
+	var rawTpl rawTemplate
+	if err := json.Unmarshal(data, &rawTpl); err != nil {
+		return nil, formatJSONError(data, err)
+	}
+	rawTpl.Comments = commentsFromRaw(data)
+	rawTpl.RawContents = data
+
+	return rawTpl.Template()
+}
+
+// ParseFile is the same as Parse but reads the template contents from the
+// file at the given path.
+func ParseFile(path string) (*Template, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tpl, err := Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	tpl.Path = path
+
+	return tpl, nil
+}
+
+// commentsFromRaw extracts any top-level key beginning with "_" into a
+// map of comments. These keys are otherwise ignored by Packer, but are
+// preserved so that templates can document themselves.
+func commentsFromRaw(data []byte) map[string]string {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+
+	var comments map[string]string
+	for k, v := range raw {
+		if !strings.HasPrefix(k, "_") {
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			continue
+		}
+
+		if comments == nil {
+			comments = make(map[string]string)
+		}
+		comments[k] = s
+	}
+
+	return comments
+}
+
+// duplicateFieldError marks an error returned by checkForDuplicateFieldsValue
+// because it found an actual repeated key, as opposed to one bubbling up
+// because the decoder hit malformed JSON. checkForDuplicateFields uses this
+// to tell the two apart.
+type duplicateFieldError struct {
+	msg string
+}
+
+func (e *duplicateFieldError) Error() string { return e.msg }
+
+// checkForDuplicateFields walks the raw JSON document looking for any
+// object that repeats a key, at any depth. encoding/json silently keeps
+// the last value for a duplicate key, which tends to hide typos in
+// templates, so we reject it outright.
+func checkForDuplicateFields(r io.Reader) error {
+	err := checkForDuplicateFieldsValue(json.NewDecoder(r))
+	if _, ok := err.(*duplicateFieldError); ok {
+		return err
+	}
+
+	// Any other error came from the decoder choking on malformed JSON
+	// rather than from us finding a duplicate key. Swallow it here and
+	// let the real decode pass in Parse surface it with line/column
+	// information instead.
+	return nil
+}
+
+func checkForDuplicateFieldsValue(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		seen := make(map[string]bool)
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			key, _ := keyTok.(string)
+			if seen[key] {
+				return &duplicateFieldError{fmt.Sprintf("template has duplicate field: %s", key)}
+			}
+			seen[key] = true
+
+			if err := checkForDuplicateFieldsValue(dec); err != nil {
+				return err
+			}
+		}
+		dec.Token() // consume '}'
+
+	case '[':
+		for dec.More() {
+			if err := checkForDuplicateFieldsValue(dec); err != nil {
+				return err
+			}
+		}
+		dec.Token() // consume ']'
+	}
+
+	return nil
+}
+
+// formatJSONError takes an error returned while decoding the template and,
+// if possible, annotates it with the line and column it occurred on so
+// that template authors can find the mistake quickly.
+func formatJSONError(data []byte, err error) error {
+	var offset int64
+	switch terr := err.(type) {
+	case *json.SyntaxError:
+		offset = terr.Offset
+	case *json.UnmarshalTypeError:
+		offset = terr.Offset
+	default:
+		return fmt.Errorf("error parsing JSON: %s", err)
+	}
+
+	line, col := 1, 1
+	last := int64(-1)
+	for i := int64(0); i < offset && int(i) < len(data); i++ {
+		if data[i] == '\n' {
+			line++
+			last = i
+		}
+	}
+	col = int(offset - last - 1)
+
+	return fmt.Errorf(
+		"error parsing JSON: %s\nline %d, column %d (offset %d)",
+		err, line, col, offset)
+}
+
+// rawTemplate is the direct JSON document format of a template. Once
+// decoded, it is turned into a Template via the Template method.
+type rawTemplate struct {
+	Comments map[string]string `json:"-"`
+
+	MinVersion              string                   `json:"min_packer_version"`
+	Description             string                   `json:"description"`
+	Variables               map[string]*rawVariable  `json:"variables"`
+	SensitiveVariables      []string                 `json:"sensitive-variables"`
+	Builders                []map[string]interface{} `json:"builders"`
+	Provisioners            []map[string]interface{} `json:"provisioners"`
+	PostProcessors          []interface{}            `json:"post-processors"`
+	ErrorCleanupProvisioner map[string]interface{}   `json:"error-cleanup-provisioner"`
+
+	RawContents []byte `json:"-"`
+}
+
+// MarshalJSON re-adds the comment keys that Comments mirrors, since they
+// don't correspond to a static field.
+func (r *rawTemplate) MarshalJSON() ([]byte, error) {
+	type rawTemplateAlias rawTemplate
+	data, err := json.Marshal((*rawTemplateAlias)(r))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Comments) == 0 {
+		return data, nil
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range r.Comments {
+		m[k] = v
+	}
+
+	return json.Marshal(m)
+}
+
+// rawVariable is a single entry of the "variables" map. A variable whose
+// JSON value is null is required and has no default; a string value is
+// used as the default. An object value is a typed variable schema: it
+// may set "default", "required", "type", "description", "sensitive",
+// and "validation" explicitly.
+type rawVariable struct {
+	Default     string
+	Required    bool
+	Type        string
+	Description string
+	Sensitive   bool
+	Validation  *rawVariableValidation
+}
+
+// rawVariableValidation is the "validation" key of a typed variable
+// schema. Condition is a boolean expression evaluated against the
+// variable's (typed) value; ErrorMessage is returned when it is false.
+type rawVariableValidation struct {
+	Condition    string `json:"condition,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+func (r *rawVariable) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		r.Required = true
+		return nil
+	}
+
+	var schema struct {
+		Default     string                 `json:"default"`
+		Required    bool                   `json:"required"`
+		Type        string                 `json:"type"`
+		Description string                 `json:"description"`
+		Sensitive   bool                   `json:"sensitive"`
+		Validation  *rawVariableValidation `json:"validation"`
+	}
+	if err := json.Unmarshal(data, &schema); err == nil {
+		r.Default = schema.Default
+		r.Required = schema.Required
+		r.Type = schema.Type
+		r.Description = schema.Description
+		r.Sensitive = schema.Sensitive
+		r.Validation = schema.Validation
+		return nil
+	}
+
+	return json.Unmarshal(data, &r.Default)
+}
+
+func (r *rawVariable) MarshalJSON() ([]byte, error) {
+	if r.Type == "" && r.Validation == nil && r.Description == "" && !r.Sensitive {
+		if r.Required {
+			return []byte("null"), nil
+		}
+
+		return json.Marshal(r.Default)
+	}
+
+	schema := struct {
+		Default     string                 `json:"default,omitempty"`
+		Required    bool                   `json:"required,omitempty"`
+		Type        string                 `json:"type,omitempty"`
+		Description string                 `json:"description,omitempty"`
+		Sensitive   bool                   `json:"sensitive,omitempty"`
+		Validation  *rawVariableValidation `json:"validation,omitempty"`
+	}{
+		Default:     r.Default,
+		Required:    r.Required,
+		Type:        r.Type,
+		Description: r.Description,
+		Sensitive:   r.Sensitive,
+		Validation:  r.Validation,
+	}
+
+	return json.Marshal(schema)
+}
+
+// Template turns the raw, directly-decoded template into the friendlier
+// Template structure that the rest of Packer consumes.
+func (r *rawTemplate) Template() (*Template, error) {
+	builders, err := buildersFromRaw(r.Builders)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioners, err := provisionersFromRaw(r.Provisioners)
+	if err != nil {
+		return nil, err
+	}
+
+	postProcessors, err := postProcessorsFromRaw(r.PostProcessors)
+	if err != nil {
+		return nil, err
+	}
+
+	var errorCleanupProvisioner *Provisioner
+	if len(r.ErrorCleanupProvisioner) > 0 {
+		errorCleanupProvisioner, err = provisionerFromRaw(r.ErrorCleanupProvisioner)
+		if err != nil {
+			return nil, fmt.Errorf("error-cleanup-provisioner: %s", err)
+		}
+	}
+
+	variables, err := variablesFromRaw(r.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	sensitiveVariables, err := sensitiveVariablesFromRaw(r.SensitiveVariables, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{
+		RawContents:             r.RawContents,
+		Description:             r.Description,
+		MinVersion:              r.MinVersion,
+		Comments:                r.Comments,
+		Variables:               variables,
+		SensitiveVariables:      sensitiveVariables,
+		Builders:                builders,
+		Provisioners:            provisioners,
+		PostProcessors:          postProcessors,
+		ErrorCleanupProvisioner: errorCleanupProvisioner,
+	}, nil
+}
+
+// Raw converts the template back into its raw, JSON-shaped representation.
+// This is primarily used to write a Template back out to disk after it
+// has been manipulated in memory.
+func (t *Template) Raw() (*rawTemplate, error) {
+	var errorCleanupProvisioner map[string]interface{}
+	if t.ErrorCleanupProvisioner != nil {
+		errorCleanupProvisioner = provisionerToRaw(t.ErrorCleanupProvisioner)
+	}
+
+	return &rawTemplate{
+		Comments:                t.Comments,
+		MinVersion:              t.MinVersion,
+		Description:             t.Description,
+		Variables:               variablesToRaw(t.Variables),
+		SensitiveVariables:      sensitiveVariablesToRaw(t.SensitiveVariables),
+		Builders:                buildersToRaw(t.Builders),
+		Provisioners:            provisionersToRaw(t.Provisioners),
+		PostProcessors:          postProcessorsToRaw(t.PostProcessors),
+		ErrorCleanupProvisioner: errorCleanupProvisioner,
+		RawContents:             t.RawContents,
+	}, nil
+}
+
+func buildersFromRaw(raw []map[string]interface{}) (map[string]*Builder, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]*Builder, len(raw))
+	for i, m := range raw {
+		typeName, ok := stringField(m, "type")
+		if !ok {
+			return nil, fmt.Errorf("builder %d: missing 'type'", i)
+		}
+
+		name := typeName
+		if n, ok := stringField(m, "name"); ok {
+			name = n
+		}
+
+		if _, ok := result[name]; ok {
+			return nil, fmt.Errorf("builder %q: duplicate builder name", name)
+		}
+
+		result[name] = &Builder{
+			Name:   name,
+			Type:   typeName,
+			Config: remainingFields(m, "type", "name"),
+		}
+	}
+
+	return result, nil
+}
+
+func buildersToRaw(builders map[string]*Builder) []map[string]interface{} {
+	if len(builders) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(builders))
+	for name := range builders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		b := builders[name]
+
+		m := make(map[string]interface{}, len(b.Config)+2)
+		for k, v := range b.Config {
+			m[k] = v
+		}
+		m["type"] = b.Type
+		if b.Name != b.Type {
+			m["name"] = b.Name
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
+func provisionersFromRaw(raw []map[string]interface{}) ([]*Provisioner, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	result := make([]*Provisioner, 0, len(raw))
+	for i, m := range raw {
+		p, err := provisionerFromRaw(m)
+		if err != nil {
+			return nil, fmt.Errorf("provisioner %d: %s", i, err)
+		}
+
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+func provisionerFromRaw(m map[string]interface{}) (*Provisioner, error) {
+	typeName, ok := stringField(m, "type")
+	if !ok {
+		return nil, fmt.Errorf("missing 'type'")
+	}
+
+	p := &Provisioner{Type: typeName}
+
+	if v, ok := m["pause_before"]; ok {
+		d, err := parseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("pause_before: %s", err)
+		}
+		p.PauseBefore = d
+	}
+
+	if v, ok := m["timeout"]; ok {
+		d, err := parseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("timeout: %s", err)
+		}
+		p.Timeout = d
+	}
+
+	if v, ok := m["max_retries"]; ok {
+		switch t := v.(type) {
+		case string:
+			p.MaxRetries = t
+		case float64:
+			p.MaxRetries = strconv.FormatFloat(t, 'f', -1, 64)
+		}
+	}
+
+	if v, ok := m["override"].(map[string]interface{}); ok {
+		p.Override = v
+	}
+
+	if v, ok := m["retry"].(map[string]interface{}); ok {
+		retry, err := retryFromRaw(v)
+		if err != nil {
+			return nil, fmt.Errorf("retry: %s", err)
+		}
+		p.Retry = retry
+	}
+
+	oe, err := onlyExceptFromRaw(m)
+	if err != nil {
+		return nil, err
+	}
+	p.OnlyExcept = oe
+
+	p.Config = remainingFields(m,
+		"type", "pause_before", "timeout", "max_retries", "retry", "override", "only", "except")
+
+	return p, nil
+}
+
+func provisionersToRaw(provisioners []*Provisioner) []map[string]interface{} {
+	if len(provisioners) == 0 {
+		return nil
+	}
+
+	result := make([]map[string]interface{}, 0, len(provisioners))
+	for _, p := range provisioners {
+		result = append(result, provisionerToRaw(p))
+	}
+
+	return result
+}
+
+func provisionerToRaw(p *Provisioner) map[string]interface{} {
+	m := make(map[string]interface{}, len(p.Config)+6)
+	for k, v := range p.Config {
+		m[k] = v
+	}
+	m["type"] = p.Type
+
+	if p.PauseBefore > 0 {
+		m["pause_before"] = p.PauseBefore.String()
+	}
+	if p.Timeout > 0 {
+		m["timeout"] = p.Timeout.String()
+	}
+	if p.MaxRetries != "" {
+		m["max_retries"] = p.MaxRetries
+	}
+	if !p.Retry.IsZero() {
+		m["retry"] = retryToRaw(p.Retry)
+	}
+	if p.Override != nil {
+		m["override"] = p.Override
+	}
+	onlyExceptToRaw(p.OnlyExcept, m)
+
+	return m
+}
+
+func postProcessorsFromRaw(raw []interface{}) ([][]*PostProcessor, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	result := make([][]*PostProcessor, 0, len(raw))
+	for i, v := range raw {
+		chain, ok := v.([]interface{})
+		if !ok {
+			chain = []interface{}{v}
+		}
+
+		pps := make([]*PostProcessor, 0, len(chain))
+		for j, elem := range chain {
+			pp, err := postProcessorFromRaw(elem)
+			if err != nil {
+				return nil, fmt.Errorf("post-processor %d.%d: %s", i, j, err)
+			}
+			pps = append(pps, pp)
+		}
+
+		result = append(result, pps)
+	}
+
+	return result, nil
+}
+
+func postProcessorFromRaw(v interface{}) (*PostProcessor, error) {
+	switch t := v.(type) {
+	case string:
+		if t == "" {
+			return nil, fmt.Errorf("missing 'type'")
+		}
+		return &PostProcessor{Name: t, Type: t}, nil
+
+	case map[string]interface{}:
+		typeName, ok := stringField(t, "type")
+		if !ok {
+			return nil, fmt.Errorf("missing 'type'")
+		}
+
+		name := typeName
+		if n, ok := stringField(t, "name"); ok {
+			name = n
+		}
+
+		pp := &PostProcessor{Name: name, Type: typeName}
+
+		if keep, ok := t["keep_input_artifact"].(bool); ok {
+			pp.KeepInputArtifact = &keep
+		}
+
+		oe, err := onlyExceptFromRaw(t)
+		if err != nil {
+			return nil, err
+		}
+		pp.OnlyExcept = oe
+
+		pp.Config = remainingFields(t, "type", "name", "keep_input_artifact", "only", "except")
+
+		return pp, nil
+
+	default:
+		return nil, fmt.Errorf("must be a string or an object")
+	}
+}
+
+func postProcessorsToRaw(pps [][]*PostProcessor) []interface{} {
+	if len(pps) == 0 {
+		return nil
+	}
+
+	result := make([]interface{}, 0, len(pps))
+	for _, chain := range pps {
+		encoded := make([]interface{}, len(chain))
+		for i, pp := range chain {
+			if pp.Name == pp.Type && pp.Config == nil && pp.KeepInputArtifact == nil &&
+				len(pp.OnlyExcept.Only) == 0 && len(pp.OnlyExcept.Except) == 0 {
+				encoded[i] = pp.Type
+				continue
+			}
+
+			encoded[i] = postProcessorToRaw(pp)
+		}
+
+		if len(encoded) == 1 {
+			result = append(result, encoded[0])
+		} else {
+			result = append(result, encoded)
+		}
+	}
+
+	return result
+}
+
+func postProcessorToRaw(pp *PostProcessor) map[string]interface{} {
+	m := make(map[string]interface{}, len(pp.Config)+4)
+	for k, v := range pp.Config {
+		m[k] = v
+	}
+	m["type"] = pp.Type
+	if pp.Name != pp.Type {
+		m["name"] = pp.Name
+	}
+	if pp.KeepInputArtifact != nil {
+		m["keep_input_artifact"] = *pp.KeepInputArtifact
+	}
+	onlyExceptToRaw(pp.OnlyExcept, m)
+
+	return m
+}
+
+func variablesFromRaw(raw map[string]*rawVariable) (map[string]*Variable, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[string]*Variable, len(raw))
+	for k, v := range raw {
+		if v == nil {
+			// A JSON null value never reaches rawVariable.UnmarshalJSON;
+			// encoding/json leaves the pointer nil instead. Treat it the
+			// same way: required, with no default.
+			v = &rawVariable{Required: true}
+		}
+
+		variable := &Variable{
+			Key:         k,
+			Default:     v.Default,
+			Required:    v.Required,
+			Type:        v.Type,
+			Description: v.Description,
+			Sensitive:   v.Sensitive,
+		}
+
+		if v.Validation != nil {
+			validation, err := variableValidationFromRaw(v.Validation)
+			if err != nil {
+				return nil, fmt.Errorf("variable %q: validation: %s", k, err)
+			}
+			variable.Validation = validation
+		}
+
+		if err := validateVariableDefault(variable); err != nil {
+			return nil, fmt.Errorf("variable %q: %s", k, err)
+		}
+
+		result[k] = variable
+	}
+
+	return result, nil
+}
+
+func variablesToRaw(vars map[string]*Variable) map[string]*rawVariable {
+	if len(vars) == 0 {
+		return nil
+	}
+
+	result := make(map[string]*rawVariable, len(vars))
+	for k, v := range vars {
+		raw := &rawVariable{
+			Default:     v.Default,
+			Required:    v.Required,
+			Type:        v.Type,
+			Description: v.Description,
+			Sensitive:   v.Sensitive,
+		}
+
+		if v.Validation != nil {
+			raw.Validation = &rawVariableValidation{
+				Condition:    v.Validation.Condition,
+				ErrorMessage: v.Validation.ErrorMessage,
+			}
+		}
+
+		result[k] = raw
+	}
+
+	return result
+}
+
+func variableValidationFromRaw(r *rawVariableValidation) (*VariableValidation, error) {
+	if r.Condition == "" {
+		return nil, fmt.Errorf("condition is required")
+	}
+
+	if _, err := parser.ParseExpr(r.Condition); err != nil {
+		return nil, fmt.Errorf("condition: %s", err)
+	}
+
+	return &VariableValidation{
+		Condition:    r.Condition,
+		ErrorMessage: r.ErrorMessage,
+	}, nil
+}
+
+// validateVariableDefault checks that a variable's default value, if any,
+// conforms to its declared type and validation rule. Values supplied at
+// build time are validated the same way via Template.ValidateVariables;
+// this catches mistakes in the template itself as early as possible.
+func validateVariableDefault(v *Variable) error {
+	if v.Default == "" {
+		return nil
+	}
+
+	if err := validateVariableValue(v, v.Default); err != nil {
+		return fmt.Errorf("default: %s", err)
+	}
+
+	return nil
+}
+
+// sensitiveVariablesFromRaw resolves the top-level "sensitive-variables"
+// list against vars, then appends any variable whose own schema set
+// "sensitive": true and isn't already present, so the two ways of
+// marking a variable sensitive stay unified.
+func sensitiveVariablesFromRaw(keys []string, vars map[string]*Variable) ([]*Variable, error) {
+	seen := make(map[string]bool, len(keys))
+	result := make([]*Variable, 0, len(keys))
+	for _, k := range keys {
+		v, ok := vars[k]
+		if !ok {
+			return nil, fmt.Errorf("sensitive-variables: unknown variable %q", k)
+		}
+		result = append(result, v)
+		seen[k] = true
+	}
+
+	extra := make([]string, 0)
+	for k, v := range vars {
+		if v.Sensitive && !seen[k] {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		result = append(result, vars[k])
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+func sensitiveVariablesToRaw(vars []*Variable) []string {
+	if len(vars) == 0 {
+		return nil
+	}
+
+	result := make([]string, len(vars))
+	for i, v := range vars {
+		result[i] = v.Key
+	}
+
+	return result
+}
+
+func onlyExceptFromRaw(m map[string]interface{}) (OnlyExcept, error) {
+	var oe OnlyExcept
+
+	if v, ok := m["only"]; ok {
+		only, err := stringSliceFromRaw(v)
+		if err != nil {
+			return oe, fmt.Errorf("only: %s", err)
+		}
+		oe.Only = only
+	}
+
+	if v, ok := m["except"]; ok {
+		except, err := stringSliceFromRaw(v)
+		if err != nil {
+			return oe, fmt.Errorf("except: %s", err)
+		}
+		oe.Except = except
+	}
+
+	return oe, nil
+}
+
+func onlyExceptToRaw(oe OnlyExcept, m map[string]interface{}) {
+	if len(oe.Only) > 0 {
+		m["only"] = oe.Only
+	}
+	if len(oe.Except) > 0 {
+		m["except"] = oe.Except
+	}
+}
+
+func retryFromRaw(m map[string]interface{}) (Retry, error) {
+	var r Retry
+
+	if v, ok := m["max_retries"]; ok {
+		n, ok := v.(float64)
+		if !ok {
+			return r, fmt.Errorf("max_retries: must be a number")
+		}
+		r.MaxRetries = int(n)
+	}
+
+	if v, ok := m["initial_interval"]; ok {
+		d, err := parseDuration(v)
+		if err != nil {
+			return r, fmt.Errorf("initial_interval: %s", err)
+		}
+		r.InitialInterval = d
+	}
+
+	if v, ok := m["max_interval"]; ok {
+		d, err := parseDuration(v)
+		if err != nil {
+			return r, fmt.Errorf("max_interval: %s", err)
+		}
+		r.MaxInterval = d
+	}
+
+	if v, ok := m["multiplier"]; ok {
+		n, ok := v.(float64)
+		if !ok {
+			return r, fmt.Errorf("multiplier: must be a number")
+		}
+		r.Multiplier = n
+	}
+
+	if v, ok := m["retry_on"]; ok {
+		retryOn, err := stringSliceFromRaw(v)
+		if err != nil {
+			return r, fmt.Errorf("retry_on: %s", err)
+		}
+		r.RetryOn = retryOn
+	}
+
+	if _, err := r.retryOnPatterns(); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func retryToRaw(r Retry) map[string]interface{} {
+	m := make(map[string]interface{})
+
+	if r.MaxRetries > 0 {
+		m["max_retries"] = r.MaxRetries
+	}
+	if r.InitialInterval > 0 {
+		m["initial_interval"] = r.InitialInterval.String()
+	}
+	if r.MaxInterval > 0 {
+		m["max_interval"] = r.MaxInterval.String()
+	}
+	if r.Multiplier > 0 {
+		m["multiplier"] = r.Multiplier
+	}
+	if len(r.RetryOn) > 0 {
+		m["retry_on"] = r.RetryOn
+	}
+
+	return m
+}
+
+func stringSliceFromRaw(v interface{}) ([]string, error) {
+	raw, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must be an array of strings")
+	}
+
+	result := make([]string, len(raw))
+	for i, r := range raw {
+		s, ok := r.(string)
+		if !ok {
+			return nil, fmt.Errorf("element %d must be a string", i)
+		}
+		result[i] = s
+	}
+
+	return result, nil
+}
+
+func stringField(m map[string]interface{}, key string) (string, bool) {
+	v, ok := m[key]
+	if !ok {
+		return "", false
+	}
+
+	s, ok := v.(string)
+	if !ok || s == "" {
+		return "", false
+	}
+
+	return s, true
+}
+
+func remainingFields(m map[string]interface{}, used ...string) map[string]interface{} {
+	skip := make(map[string]bool, len(used))
+	for _, k := range used {
+		skip[k] = true
+	}
+
+	var result map[string]interface{}
+	for k, v := range m {
+		if skip[k] {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]interface{})
+		}
+		result[k] = v
+	}
+
+	return result
+}
+
+func parseDuration(v interface{}) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("must be a duration string, such as \"5s\"")
+	}
+
+	return time.ParseDuration(s)
+}
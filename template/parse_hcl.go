@@ -0,0 +1,358 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// hclTemplateSchema describes the top level of an HCL2 template. Every
+// block decodes into the same rawTemplate shapes that the JSON decoder
+// produces, so the rest of the parsing pipeline (buildersFromRaw,
+// provisionersFromRaw, etc.) is shared between both formats.
+var hclTemplateSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "description"},
+		{Name: "min_packer_version"},
+		{Name: "sensitive_variables"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "builder", LabelNames: []string{"type"}},
+		{Type: "provisioner", LabelNames: []string{"type"}},
+		{Type: "post-processors"},
+		{Type: "error-cleanup-provisioner", LabelNames: []string{"type"}},
+	},
+}
+
+var hclPostProcessorsSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "post-processor", LabelNames: []string{"type"}},
+	},
+}
+
+// ParseHCL parses an HCL2 template from the given reader r and returns
+// the parsed template. filename is used only to annotate diagnostics and
+// does not need to exist on disk.
+func ParseHCL(r io.Reader, filename string) (*Template, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading template: %s", err)
+	}
+
+	f, diags := hclparse.NewParser().ParseHCL(data, filename)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	content, diags := f.Body.Content(hclTemplateSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	rawTpl := rawTemplate{RawContents: data}
+
+	if attr, ok := content.Attributes["description"]; ok {
+		if err := hclAttrString(attr, &rawTpl.Description); err != nil {
+			return nil, err
+		}
+	}
+	if attr, ok := content.Attributes["min_packer_version"]; ok {
+		if err := hclAttrString(attr, &rawTpl.MinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if attr, ok := content.Attributes["sensitive_variables"]; ok {
+		vars, err := hclAttrStringSlice(attr)
+		if err != nil {
+			return nil, err
+		}
+		rawTpl.SensitiveVariables = vars
+	}
+
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "variable":
+			v, err := hclVariable(block)
+			if err != nil {
+				return nil, err
+			}
+			if rawTpl.Variables == nil {
+				rawTpl.Variables = make(map[string]*rawVariable)
+			}
+			rawTpl.Variables[block.Labels[0]] = v
+
+		case "builder":
+			m, err := hclBodyToMap(block.Body)
+			if err != nil {
+				return nil, err
+			}
+			m["type"] = block.Labels[0]
+			rawTpl.Builders = append(rawTpl.Builders, m)
+
+		case "provisioner":
+			m, err := hclBodyToMap(block.Body)
+			if err != nil {
+				return nil, err
+			}
+			m["type"] = block.Labels[0]
+			rawTpl.Provisioners = append(rawTpl.Provisioners, m)
+
+		case "post-processors":
+			chain, err := hclPostProcessorChain(block.Body)
+			if err != nil {
+				return nil, err
+			}
+			rawTpl.PostProcessors = append(rawTpl.PostProcessors, chain)
+
+		case "error-cleanup-provisioner":
+			m, err := hclBodyToMap(block.Body)
+			if err != nil {
+				return nil, err
+			}
+			m["type"] = block.Labels[0]
+			rawTpl.ErrorCleanupProvisioner = m
+		}
+	}
+
+	return rawTpl.Template()
+}
+
+// ParseHCLFile is the same as ParseHCL but reads the template contents
+// from the file at the given path.
+func ParseHCLFile(path string) (*Template, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tpl, err := ParseHCL(f, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	tpl.Path = abs
+
+	return tpl, nil
+}
+
+// ParseAny parses the template at path, detecting from its extension
+// whether it is an HCL2 template (any path ending in .hcl, such as
+// .pkr.hcl) or a legacy JSON template (.pkr.json, or anything else).
+// Either format decodes into the same *Template.
+func ParseAny(path string) (*Template, error) {
+	if strings.HasSuffix(path, ".hcl") {
+		return ParseHCLFile(path)
+	}
+
+	return ParseFile(path)
+}
+
+func hclVariable(block *hcl.Block) (*rawVariable, error) {
+	name := block.Labels[0]
+
+	m, err := hclBodyToMap(block.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &rawVariable{}
+	if d, ok := m["default"]; ok {
+		s, ok := d.(string)
+		if !ok {
+			return nil, fmt.Errorf("variable %q: default must be a string", name)
+		}
+		v.Default = s
+	} else {
+		v.Required = true
+	}
+
+	if t, ok := m["type"]; ok {
+		s, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("variable %q: type must be a string", name)
+		}
+		v.Type = s
+	}
+
+	if d, ok := m["description"]; ok {
+		s, ok := d.(string)
+		if !ok {
+			return nil, fmt.Errorf("variable %q: description must be a string", name)
+		}
+		v.Description = s
+	}
+
+	if s, ok := m["sensitive"]; ok {
+		b, ok := s.(bool)
+		if !ok {
+			return nil, fmt.Errorf("variable %q: sensitive must be a bool", name)
+		}
+		v.Sensitive = b
+	}
+
+	if val, ok := m["validation"]; ok {
+		validation, err := hclVariableValidation(name, val)
+		if err != nil {
+			return nil, err
+		}
+		v.Validation = validation
+	}
+
+	return v, nil
+}
+
+// hclVariableValidation converts the map decoded from a variable block's
+// "validation" attribute (an HCL object literal) into the same
+// rawVariableValidation the JSON decoder produces from a "validation" key.
+func hclVariableValidation(name string, raw interface{}) (*rawVariableValidation, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("variable %q: validation must be an object", name)
+	}
+
+	validation := &rawVariableValidation{}
+	if c, ok := m["condition"]; ok {
+		s, ok := c.(string)
+		if !ok {
+			return nil, fmt.Errorf("variable %q: validation.condition must be a string", name)
+		}
+		validation.Condition = s
+	}
+	if e, ok := m["error_message"]; ok {
+		s, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("variable %q: validation.error_message must be a string", name)
+		}
+		validation.ErrorMessage = s
+	}
+
+	return validation, nil
+}
+
+func hclPostProcessorChain(body hcl.Body) ([]interface{}, error) {
+	content, diags := body.Content(hclPostProcessorsSchema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	chain := make([]interface{}, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		m, err := hclBodyToMap(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		m["type"] = block.Labels[0]
+		chain = append(chain, m)
+	}
+
+	return chain, nil
+}
+
+// hclBodyToMap decodes every attribute in body into a plain
+// map[string]interface{}, the same shape buildersFromRaw and friends
+// already know how to consume from the JSON decoder.
+func hclBodyToMap(body hcl.Body) (map[string]interface{}, error) {
+	attrs, diags := body.JustAttributes()
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	m := make(map[string]interface{}, len(attrs))
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		goVal, err := ctyToGo(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		m[name] = goVal
+	}
+
+	return m, nil
+}
+
+func hclAttrString(attr *hcl.Attribute, out *string) error {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	s, err := convert.Convert(val, cty.String)
+	if err != nil {
+		return err
+	}
+	*out = s.AsString()
+	return nil
+}
+
+func hclAttrStringSlice(attr *hcl.Attribute) ([]string, error) {
+	val, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	goVal, err := ctyToGo(val)
+	if err != nil {
+		return nil, err
+	}
+
+	return stringSliceFromRaw(goVal)
+}
+
+func ctyToGo(val cty.Value) (interface{}, error) {
+	if val.IsNull() {
+		return nil, nil
+	}
+
+	t := val.Type()
+	switch {
+	case t == cty.String:
+		return val.AsString(), nil
+	case t == cty.Bool:
+		return val.True(), nil
+	case t == cty.Number:
+		f, _ := val.AsBigFloat().Float64()
+		return f, nil
+	case t.IsListType(), t.IsTupleType(), t.IsSetType():
+		result := make([]interface{}, 0)
+		for it := val.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			gv, err := ctyToGo(ev)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, gv)
+		}
+		return result, nil
+	case t.IsMapType(), t.IsObjectType():
+		result := make(map[string]interface{})
+		for it := val.ElementIterator(); it.Next(); {
+			kv, ev := it.Element()
+			gv, err := ctyToGo(ev)
+			if err != nil {
+				return nil, err
+			}
+			result[kv.AsString()] = gv
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported HCL value type %s", t.FriendlyName())
+	}
+}
@@ -19,6 +19,10 @@ func boolPointer(tf bool) *bool {
 	return &tf
 }
 
+func fixtureDir(n string) string {
+	return filepath.Join("test-fixtures", n)
+}
+
 func TestParse(t *testing.T) {
 	cases := []struct {
 		File   string
@@ -120,6 +124,31 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-provisioner-retry-backoff.json",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type: "something",
+						Retry: Retry{
+							MaxRetries:      3,
+							InitialInterval: 1 * time.Second,
+							MaxInterval:     30 * time.Second,
+							Multiplier:      2,
+							RetryOn:         []string{"(?i)timeout", "connection reset"},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-provisioner-retry-bad-pattern.json",
+			nil,
+			true,
+		},
+
 		{
 			"parse-provisioner-timeout.json",
 			&Template{
@@ -184,6 +213,42 @@ func TestParse(t *testing.T) {
 			true,
 		},
 
+		{
+			"parse-error-cleanup-provisioner.json",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type: "something",
+					},
+				},
+				ErrorCleanupProvisioner: &Provisioner{
+					Type: "shell",
+					Config: map[string]interface{}{
+						"inline": "echo 'cleaning up'",
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-error-cleanup-provisioner.pkr.hcl",
+			&Template{
+				Provisioners: []*Provisioner{
+					{
+						Type: "something",
+					},
+				},
+				ErrorCleanupProvisioner: &Provisioner{
+					Type: "shell",
+					Config: map[string]interface{}{
+						"inline": "echo 'cleaning up'",
+					},
+				},
+			},
+			false,
+		},
+
 		{
 			"parse-variable-default.json",
 			&Template{
@@ -210,6 +275,124 @@ func TestParse(t *testing.T) {
 			false,
 		},
 
+		{
+			"parse-variable-typed.json",
+			&Template{
+				Variables: map[string]*Variable{
+					"string_var": {
+						Key:         "string_var",
+						Default:     "hello",
+						Type:        "string",
+						Description: "a string variable",
+					},
+					"number_var": {
+						Key:     "number_var",
+						Default: "3",
+						Type:    "number",
+						Validation: &VariableValidation{
+							Condition:    "value >= 0 && value <= 10",
+							ErrorMessage: "must be between 0 and 10",
+						},
+					},
+					"bool_var": {
+						Key:     "bool_var",
+						Default: "true",
+						Type:    "bool",
+					},
+					"list_var": {
+						Key:     "list_var",
+						Default: `["a", "b"]`,
+						Type:    "list",
+					},
+					"map_var": {
+						Key:     "map_var",
+						Default: `{"key": "value"}`,
+						Type:    "map",
+					},
+					"secret_var": {
+						Key:       "secret_var",
+						Default:   "hunter2",
+						Type:      "string",
+						Sensitive: true,
+					},
+				},
+				SensitiveVariables: []*Variable{
+					{
+						Key:       "secret_var",
+						Default:   "hunter2",
+						Type:      "string",
+						Sensitive: true,
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-variable-typed.pkr.hcl",
+			&Template{
+				Variables: map[string]*Variable{
+					"string_var": {
+						Key:         "string_var",
+						Default:     "hello",
+						Type:        "string",
+						Description: "a string variable",
+					},
+					"number_var": {
+						Key:     "number_var",
+						Default: "3",
+						Type:    "number",
+						Validation: &VariableValidation{
+							Condition:    "value >= 0 && value <= 10",
+							ErrorMessage: "must be between 0 and 10",
+						},
+					},
+					"bool_var": {
+						Key:     "bool_var",
+						Default: "true",
+						Type:    "bool",
+					},
+					"list_var": {
+						Key:     "list_var",
+						Default: `["a", "b"]`,
+						Type:    "list",
+					},
+					"map_var": {
+						Key:     "map_var",
+						Default: `{"key": "value"}`,
+						Type:    "map",
+					},
+					"secret_var": {
+						Key:       "secret_var",
+						Default:   "hunter2",
+						Type:      "string",
+						Sensitive: true,
+					},
+				},
+				SensitiveVariables: []*Variable{
+					{
+						Key:       "secret_var",
+						Default:   "hunter2",
+						Type:      "string",
+						Sensitive: true,
+					},
+				},
+			},
+			false,
+		},
+
+		{
+			"parse-variable-invalid.json",
+			nil,
+			true,
+		},
+
+		{
+			"parse-variable-validation-failed.json",
+			nil,
+			true,
+		},
+
 		{
 			"parse-pp-basic.json",
 			&Template{
@@ -488,11 +671,114 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+
+		/*
+		 * HCL2 (must parse to the same *Template as the JSON equivalent
+		 * above, except for Comments, which only JSON "_" keys populate)
+		 */
+		{
+			"parse-monolithic.pkr.hcl",
+			&Template{
+				Description: "Description Test",
+				MinVersion:  "1.3.0",
+				SensitiveVariables: []*Variable{
+					{
+						Required: false,
+						Key:      "one",
+						Default:  "1",
+					},
+				},
+				Variables: map[string]*Variable{
+					"one": {
+						Required: false,
+						Key:      "one",
+						Default:  "1",
+					},
+					"two": {
+						Required: false,
+						Key:      "two",
+						Default:  "2",
+					},
+					"three": {
+						Required: true,
+						Key:      "three",
+						Default:  "",
+					},
+				},
+				Builders: map[string]*Builder{
+					"amazon-ebs": {
+						Name: "amazon-ebs",
+						Type: "amazon-ebs",
+						Config: map[string]interface{}{
+							"ami_name":      "AMI Name",
+							"instance_type": "t2.micro",
+							"ssh_username":  "ec2-user",
+							"source_ami":    "ami-aaaaaaaaaaaaaa",
+						},
+					},
+					"docker": {
+						Name: "docker",
+						Type: "docker",
+						Config: map[string]interface{}{
+							"image":       "ubuntu",
+							"export_path": "image.tar",
+						},
+					},
+				},
+				Provisioners: []*Provisioner{
+					{
+						Type: "shell",
+						Config: map[string]interface{}{
+							"script": "script.sh",
+						},
+					},
+					{
+						Type: "shell",
+						Config: map[string]interface{}{
+							"script": "script.sh",
+						},
+						Override: map[string]interface{}{
+							"docker": map[string]interface{}{
+								"execute_command": "echo 'override'",
+							},
+						},
+					},
+				},
+				PostProcessors: [][]*PostProcessor{
+					{
+						{
+							Name: "compress",
+							Type: "compress",
+						},
+						{
+							Name: "vagrant",
+							Type: "vagrant",
+							OnlyExcept: OnlyExcept{
+								Only: []string{"docker"},
+							},
+						},
+					},
+					{
+						{
+							Name: "shell-local",
+							Type: "shell-local",
+							Config: map[string]interface{}{
+								"inline": []interface{}{"echo foo"},
+							},
+							OnlyExcept: OnlyExcept{
+								Except: []string{"amazon-ebs"},
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
 	}
 
 	for i, tc := range cases {
 		path, _ := filepath.Abs(fixtureDir(tc.File))
-		tpl, err := ParseFile(fixtureDir(tc.File))
+		tpl, err := ParseAny(fixtureDir(tc.File))
 		if (err != nil) != tc.Err {
 			t.Fatalf("%s\n\nerr: %s", tc.File, err)
 		}
@@ -555,14 +841,17 @@ func TestParse_contents(t *testing.T) {
 }
 
 func TestParse_bad(t *testing.T) {
+	// The offsets below are the *json.SyntaxError offsets encoding/json
+	// reports for these fixtures; the line and column are formatJSONError's
+	// translation of each offset into a 1-based position in the fixture.
 	cases := []struct {
 		File     string
 		Expected string
 	}{
 		{"error-beginning.json", "line 1, column 1 (offset 1)"},
-		{"error-middle.json", "line 5, column 6 (offset 50)"},
-		{"error-end.json", "line 1, column 30 (offset 30)"},
-		{"malformed.json", "line 16, column 3 (offset 433)"},
+		{"error-middle.json", "line 4, column 7 (offset 31)"},
+		{"error-end.json", "line 1, column 28 (offset 28)"},
+		{"malformed.json", "line 15, column 3 (offset 191)"},
 	}
 	for _, tc := range cases {
 		_, err := ParseFile(fixtureDir(tc.File))
@@ -0,0 +1,12 @@
+package template
+
+// PostProcessor is a struct that represents a post-processor as it is
+// parsed out of a template.
+type PostProcessor struct {
+	Name string
+	Type string
+
+	Config            map[string]interface{}
+	KeepInputArtifact *bool
+	OnlyExcept        OnlyExcept
+}
@@ -0,0 +1,18 @@
+package template
+
+import "time"
+
+// Provisioner is a struct that represents a provisioner as it is parsed
+// out of a template.
+type Provisioner struct {
+	Type string
+
+	Config     map[string]interface{}
+	Override   map[string]interface{}
+	OnlyExcept OnlyExcept
+
+	PauseBefore time.Duration
+	MaxRetries  string
+	Retry       Retry
+	Timeout     time.Duration
+}
@@ -0,0 +1,112 @@
+package template
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"time"
+)
+
+// Retry is a struct that represents the `retry` block on a provisioner:
+// a bounded number of attempts at capped exponential backoff, with
+// jitter, restricted to errors that look retryable.
+type Retry struct {
+	MaxRetries      int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	RetryOn         []string
+}
+
+// IsZero reports whether r is the zero value, i.e. no retry block was
+// set on the provisioner.
+func (r Retry) IsZero() bool {
+	return r.MaxRetries == 0 &&
+		r.InitialInterval == 0 &&
+		r.MaxInterval == 0 &&
+		r.Multiplier == 0 &&
+		len(r.RetryOn) == 0
+}
+
+// Run calls fn, retrying it with capped exponential backoff and jitter
+// when it returns an error matching one of RetryOn's patterns (or any
+// error, if RetryOn is empty), up to MaxRetries additional attempts.
+func (r Retry) Run(fn func() error) error {
+	patterns, err := r.retryOnPatterns()
+	if err != nil {
+		return err
+	}
+
+	interval := r.InitialInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= r.MaxRetries; attempt++ {
+		lastErr = fn()
+		if lastErr == nil {
+			return nil
+		}
+
+		if attempt == r.MaxRetries || !matchesRetryOn(patterns, lastErr) {
+			return lastErr
+		}
+
+		time.Sleep(r.backoff(attempt, interval))
+	}
+
+	return lastErr
+}
+
+// backoff returns the capped, jittered delay to wait before the retry
+// attempt numbered attempt (0-indexed), given the base interval.
+func (r Retry) backoff(attempt int, base time.Duration) time.Duration {
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	d := float64(base) * math.Pow(multiplier, float64(attempt))
+	if r.MaxInterval > 0 && d > float64(r.MaxInterval) {
+		d = float64(r.MaxInterval)
+	}
+
+	// Full jitter: a random delay between 0 and the computed backoff,
+	// so that many concurrently-retrying provisioners don't all wake up
+	// and hammer the same endpoint at once.
+	return time.Duration(d * rand.Float64())
+}
+
+func (r Retry) retryOnPatterns() ([]*regexp.Regexp, error) {
+	if len(r.RetryOn) == 0 {
+		return nil, nil
+	}
+
+	patterns := make([]*regexp.Regexp, len(r.RetryOn))
+	for i, p := range r.RetryOn {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("retry_on[%d]: %s", i, err)
+		}
+		patterns[i] = re
+	}
+
+	return patterns, nil
+}
+
+func matchesRetryOn(patterns []*regexp.Regexp, err error) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	msg := err.Error()
+	for _, p := range patterns {
+		if p.MatchString(msg) {
+			return true
+		}
+	}
+
+	return false
+}
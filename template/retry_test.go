@@ -0,0 +1,62 @@
+package template
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetry_Run(t *testing.T) {
+	r := Retry{MaxRetries: 3, InitialInterval: time.Millisecond}
+
+	attempts := 0
+	err := r.Run(func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetry_Run_exhausted(t *testing.T) {
+	r := Retry{MaxRetries: 2, InitialInterval: time.Millisecond}
+
+	attempts := 0
+	err := r.Run(func() error {
+		attempts++
+		return errors.New("always fails")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts (1 + 2 retries), got %d", attempts)
+	}
+}
+
+func TestRetry_Run_retryOn(t *testing.T) {
+	r := Retry{
+		MaxRetries:      3,
+		InitialInterval: time.Millisecond,
+		RetryOn:         []string{"(?i)timeout"},
+	}
+
+	attempts := 0
+	err := r.Run(func() error {
+		attempts++
+		return errors.New("permission denied")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt since error doesn't match retry_on, got %d", attempts)
+	}
+}
@@ -0,0 +1,62 @@
+// Package template provides the format and parser for Packer templates.
+//
+// Templates can currently be decoded from either Packer's legacy JSON
+// format or from HCL2 via Parse/ParseFile/ParseHCL/ParseHCLFile/ParseAny.
+// Regardless of source format, a template always decodes into the same
+// *Template value.
+package template
+
+import "fmt"
+
+// Template represents the parsed template that is used to configure
+// a Packer build.
+type Template struct {
+	// Path is the path to the file that this template was parsed from,
+	// if it was parsed from a file.
+	Path string
+
+	// RawContents is the raw bytes that were used to parse this template.
+	RawContents []byte
+
+	Description string
+	MinVersion  string
+
+	// Comments is a map of top-level keys prefixed with "_" to their
+	// values. Packer ignores these keys functionally but preserves them
+	// so templates can document themselves.
+	Comments map[string]string
+
+	Variables          map[string]*Variable
+	SensitiveVariables []*Variable
+
+	Builders       map[string]*Builder
+	Provisioners   []*Provisioner
+	PostProcessors [][]*PostProcessor
+
+	// ErrorCleanupProvisioner, if set, is a provisioner that Packer runs
+	// to clean up a half-finished build when the build returns an error.
+	ErrorCleanupProvisioner *Provisioner
+}
+
+// ValidateVariables checks a set of variable values against t.Variables'
+// declared Type and Validation rules. values supplies the value to
+// check for a variable by key; a variable missing from values falls
+// back to its Default, and is skipped entirely if it has neither a
+// supplied value nor a default.
+func (t *Template) ValidateVariables(values map[string]string) error {
+	for key, v := range t.Variables {
+		raw, ok := values[key]
+		if !ok {
+			raw = v.Default
+		}
+		if raw == "" {
+			continue
+		}
+
+		if err := validateVariableValue(v, raw); err != nil {
+			return fmt.Errorf("variable %q: %s", key, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// typedVariableValue parses raw according to typ, the Type declared on a
+// Variable, returning a string, float64, bool, []interface{}, or
+// map[string]interface{} suitable for binding to a VariableValidation's
+// Condition.
+func typedVariableValue(typ, raw string) (interface{}, error) {
+	switch typ {
+	case "", "string":
+		return raw, nil
+	case "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return n, nil
+	case "bool":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be a bool")
+		}
+		return b, nil
+	case "list":
+		var l []interface{}
+		if err := json.Unmarshal([]byte(raw), &l); err != nil {
+			return nil, fmt.Errorf("must be a JSON list")
+		}
+		return l, nil
+	case "map":
+		var m map[string]interface{}
+		if err := json.Unmarshal([]byte(raw), &m); err != nil {
+			return nil, fmt.Errorf("must be a JSON map")
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", typ)
+	}
+}
+
+// validateVariableValue checks raw against v's declared Type and, if
+// present, its Validation rule.
+func validateVariableValue(v *Variable, raw string) error {
+	typed, err := typedVariableValue(v.Type, raw)
+	if err != nil {
+		return err
+	}
+
+	if v.Validation == nil {
+		return nil
+	}
+
+	ok, err := v.Validation.evaluate(typed)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if v.Validation.ErrorMessage != "" {
+			return fmt.Errorf(v.Validation.ErrorMessage)
+		}
+		return fmt.Errorf("condition %q not satisfied", v.Validation.Condition)
+	}
+
+	return nil
+}
+
+// evaluate parses v.Condition as a Go boolean expression and evaluates
+// it with the identifier `value` bound to typedValue (a string, float64,
+// bool, []interface{}, or map[string]interface{}, matching the
+// variable's declared Type).
+func (v *VariableValidation) evaluate(typedValue interface{}) (bool, error) {
+	expr, err := parser.ParseExpr(v.Condition)
+	if err != nil {
+		return false, fmt.Errorf("condition: %s", err)
+	}
+
+	result, err := evalValidationExpr(expr, typedValue)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition: must evaluate to a bool")
+	}
+
+	return b, nil
+}
+
+func evalValidationExpr(expr ast.Expr, value interface{}) (interface{}, error) {
+	switch e := expr.(type) {
+	case *ast.ParenExpr:
+		return evalValidationExpr(e.X, value)
+
+	case *ast.Ident:
+		switch e.Name {
+		case "value":
+			return value, nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("condition: unknown identifier %q", e.Name)
+
+	case *ast.BasicLit:
+		switch e.Kind {
+		case token.INT, token.FLOAT:
+			return strconv.ParseFloat(e.Value, 64)
+		case token.STRING:
+			return strconv.Unquote(e.Value)
+		}
+		return nil, fmt.Errorf("condition: unsupported literal %q", e.Value)
+
+	case *ast.UnaryExpr:
+		return evalValidationUnaryExpr(e, value)
+
+	case *ast.BinaryExpr:
+		return evalValidationBinaryExpr(e, value)
+	}
+
+	return nil, fmt.Errorf("condition: unsupported expression")
+}
+
+func evalValidationUnaryExpr(e *ast.UnaryExpr, value interface{}) (interface{}, error) {
+	x, err := evalValidationExpr(e.X, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Op != token.NOT {
+		return nil, fmt.Errorf("condition: unsupported operator %s", e.Op)
+	}
+
+	b, ok := x.(bool)
+	if !ok {
+		return nil, fmt.Errorf("condition: ! requires a bool")
+	}
+
+	return !b, nil
+}
+
+func evalValidationBinaryExpr(e *ast.BinaryExpr, value interface{}) (interface{}, error) {
+	if e.Op == token.LAND || e.Op == token.LOR {
+		x, err := evalValidationExpr(e.X, value)
+		if err != nil {
+			return nil, err
+		}
+		xb, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("condition: %s requires bool operands", e.Op)
+		}
+		if e.Op == token.LAND && !xb {
+			return false, nil
+		}
+		if e.Op == token.LOR && xb {
+			return true, nil
+		}
+
+		y, err := evalValidationExpr(e.Y, value)
+		if err != nil {
+			return nil, err
+		}
+		yb, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("condition: %s requires bool operands", e.Op)
+		}
+		return yb, nil
+	}
+
+	x, err := evalValidationExpr(e.X, value)
+	if err != nil {
+		return nil, err
+	}
+	y, err := evalValidationExpr(e.Y, value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch xv := x.(type) {
+	case float64:
+		yv, ok := y.(float64)
+		if !ok {
+			return nil, fmt.Errorf("condition: cannot compare number to %T", y)
+		}
+		return evalNumberComparison(e.Op, xv, yv)
+
+	case string:
+		yv, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("condition: cannot compare string to %T", y)
+		}
+		return evalEqualityComparison(e.Op, xv == yv)
+
+	case bool:
+		yv, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("condition: cannot compare bool to %T", y)
+		}
+		return evalEqualityComparison(e.Op, xv == yv)
+	}
+
+	return nil, fmt.Errorf("condition: unsupported operator %s for %T", e.Op, x)
+}
+
+func evalNumberComparison(op token.Token, x, y float64) (interface{}, error) {
+	switch op {
+	case token.EQL:
+		return x == y, nil
+	case token.NEQ:
+		return x != y, nil
+	case token.LSS:
+		return x < y, nil
+	case token.LEQ:
+		return x <= y, nil
+	case token.GTR:
+		return x > y, nil
+	case token.GEQ:
+		return x >= y, nil
+	}
+
+	return nil, fmt.Errorf("condition: unsupported operator %s for numbers", op)
+}
+
+func evalEqualityComparison(op token.Token, equal bool) (interface{}, error) {
+	switch op {
+	case token.EQL:
+		return equal, nil
+	case token.NEQ:
+		return !equal, nil
+	}
+
+	return nil, fmt.Errorf("condition: unsupported operator %s", op)
+}
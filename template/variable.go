@@ -0,0 +1,40 @@
+package template
+
+// Variable is a struct that represents a single variable as it is parsed
+// out of a template.
+type Variable struct {
+	Key      string
+	Default  string
+	Required bool
+
+	// Type constrains the values that Default (and, at build time, any
+	// value supplied for this variable) may take: one of "string",
+	// "number", "bool", "list", or "map". An empty Type is equivalent
+	// to "string". A "list" or "map" value is carried as a JSON-encoded
+	// string in Default.
+	Type string
+
+	// Description documents the variable for template authors. Packer
+	// does not otherwise act on it.
+	Description string
+
+	// Sensitive marks the variable so its value is elided from Packer's
+	// logs and UI output. A Variable with Sensitive set is also present
+	// in the owning Template's SensitiveVariables.
+	Sensitive bool
+
+	// Validation holds an additional rule that Default (and, at build
+	// time, any value supplied for this variable) must satisfy. It is
+	// nil if the variable declares no validation rule.
+	Validation *VariableValidation
+}
+
+// VariableValidation is the `validation` block on a Variable. Condition
+// is a boolean Go expression evaluated with the variable's (typed)
+// value bound to the identifier `value`, such as `value >= 0 && value
+// <= 100`. ErrorMessage is the error returned when Condition evaluates
+// to false.
+type VariableValidation struct {
+	Condition    string
+	ErrorMessage string
+}